@@ -17,7 +17,9 @@ package spanstore
 
 import (
 	"context"
+	"errors"
 	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -35,14 +37,62 @@ const (
 	serviceType            = "service"
 	serviceCacheTTLDefault = 12 * time.Hour
 	indexCacheTTLDefault   = 48 * time.Hour
+
+	// ilmInitialIndexSuffix is appended to the write alias' index prefix when
+	// bootstrapping the first backing index for an ILM-managed rollover alias.
+	ilmInitialIndexSuffix = "-000001"
+
+	bulkWorkersDefault       = 1
+	bulkActionsDefault       = 1000
+	bulkSizeDefault          = 5 * 1024 * 1024 // 5MB
+	bulkFlushIntervalDefault = 200 * time.Millisecond
+	maxRetriesDefault        = 3
+
+	bulkQueueSizeFactor = 2 // bulkQueue capacity, as a multiple of bulkWorkers*bulkActions
+
+	backoffBase = 100 * time.Millisecond
+	backoffMax  = 10 * time.Second
+
+	// shardFailureCacheCapacity bounds the number of distinct indices this
+	// writer tracks bulk-failure counters for at once.
+	shardFailureCacheCapacity = 1000
+)
+
+var (
+	errRolloverRequiresILM = errors.New("rollover requires SpanWriterParams.UseILM to be enabled")
+	errBulkQueueFull       = errors.New("elasticsearch bulk queue is full")
 )
 
 type spanWriterMetrics struct {
 	indexCreate *storageMetrics.WriteMetrics
+	bulkIndex   *storageMetrics.WriteMetrics
+}
+
+// bulkItem is a single pending write, queued until a worker flushes it to
+// Elasticsearch as part of a bulk request.
+type bulkItem struct {
+	indexName string
+	typ       string
+	doc       *dbmodel.Span
+	attempts  int
 }
 
 type serviceWriter func(string, *dbmodel.Span)
 
+// DeadLetterSink receives spans whose bulk indexing to Elasticsearch
+// permanently failed (a non-retryable status, retries exhausted, or a
+// failure while the writer is closing), along with the reason
+// Elasticsearch gave for the failure. Implementations (e.g. file- or
+// Kafka-backed) let operators recover data during ES outages instead of
+// losing spans outright.
+type DeadLetterSink interface {
+	// Send is called once per span that could not be indexed. reason is
+	// the error Elasticsearch returned for the failed bulk item.
+	Send(ctx context.Context, span *dbmodel.Span, reason string) error
+	// Flush blocks until any buffered spans have been written out.
+	Flush(ctx context.Context) error
+}
+
 // SpanWriter is a wrapper around elastic.Client
 type SpanWriter struct {
 	client           func() es.Client
@@ -52,6 +102,57 @@ type SpanWriter struct {
 	serviceWriter    serviceWriter
 	spanConverter    dbmodel.FromDomain
 	spanServiceIndex spanAndServiceIndexFn
+	useILM           bool
+	ilmPolicyName    string
+	rolloverMaxAge   time.Duration
+	rolloverMaxDocs  int
+	rolloverMaxSize  string
+
+	metricsFactory    metrics.Factory
+	bulkQueue         chan *bulkItem
+	bulkWorkers       int
+	bulkActions       int
+	bulkSize          int
+	bulkFlushInterval time.Duration
+	maxRetries        int
+	bulkWg            sync.WaitGroup
+	closeCh           chan struct{}
+	closeOnce         sync.Once
+
+	// retryWg tracks every bulk item currently scheduled for a backed-off
+	// retry, so Close() can wait for them instead of abandoning them
+	// mid-backoff. pendingRetries lets Close() cancel the outstanding
+	// timers and flush those items immediately rather than waiting out
+	// the remaining backoff.
+	retryWg        sync.WaitGroup
+	pendingMu      sync.Mutex
+	pendingRetries map[*bulkItem]*time.Timer
+
+	// shardFailureCache bounds the set of per-index bulk-failure counters
+	// this writer will ever hold onto; without UseReadWriteAliases/UseILM,
+	// indices are date-suffixed and a new one appears every day, so an
+	// unbounded map here would leak both memory and metrics-registry series
+	// for the life of the process.
+	shardFailureCache cache.Cache
+
+	tenantLocksMu sync.Mutex
+	// tenantLocks serializes ensureTenantTemplates per tenant, so a burst of
+	// concurrent first-writes for a brand-new tenant results in exactly one
+	// round of CreateTemplate/bootstrapWriteIndex calls instead of every goroutine in
+	// the burst racing to create the same templates and write index.
+	tenantLocks map[string]*sync.Mutex
+
+	deadLetterSink DeadLetterSink
+
+	tenantKey            string
+	templatesIndexPrefix string
+	spanTemplate         string
+	serviceTemplate      string
+	// tenantsBootstrapped tracks which tenants have already had their
+	// templates (and, under UseILM, write index) created, so it must
+	// hold every tenant seen in the cluster's lifetime rather than being
+	// subject to the small, fixed-size eviction used by indexCache.
+	tenantsBootstrapped sync.Map
 }
 
 // SpanWriterParams holds constructor parameters for NewSpanWriter
@@ -69,6 +170,51 @@ type SpanWriterParams struct {
 	UseReadWriteAliases    bool
 	ServiceCacheTTL        time.Duration
 	IndexCacheTTL          time.Duration
+
+	// UseILM switches index management over to Elasticsearch's Index
+	// Lifecycle Management: writes target a rollover alias backed by an
+	// `is_write_index` index, instead of the date-suffixed or `-write`
+	// alias naming used by UseReadWriteAliases.
+	UseILM bool
+	// ILMPolicyName is the name of the ILM policy CreateTemplates installs
+	// and attaches to the rollover alias.
+	ILMPolicyName string
+	// RolloverMaxAge is the ILM policy's max_age rollover condition.
+	RolloverMaxAge time.Duration
+	// RolloverMaxDocs is the ILM policy's max_docs rollover condition.
+	RolloverMaxDocs int
+	// RolloverMaxSize is the ILM policy's max_size rollover condition,
+	// e.g. "50gb".
+	RolloverMaxSize string
+
+	// BulkWorkers is the number of goroutines flushing batches to
+	// Elasticsearch concurrently. Defaults to 1.
+	BulkWorkers int
+	// BulkActions is the number of queued items that triggers a flush.
+	// Defaults to 1000.
+	BulkActions int
+	// BulkSize is the cumulative document size, in bytes, that triggers a
+	// flush. Defaults to 5MB.
+	BulkSize int
+	// BulkFlushInterval is the maximum time a partially-filled batch is
+	// held before being flushed. Defaults to 200ms.
+	BulkFlushInterval time.Duration
+	// MaxRetries bounds how many times a failed bulk item is retried
+	// after a retryable (429/503) failure before it is dropped. Defaults
+	// to 3.
+	MaxRetries int
+
+	// DeadLetterSink, if set, receives spans that permanently fail bulk
+	// indexing (a non-retryable status, or MaxRetries exhausted), instead
+	// of them being dropped.
+	DeadLetterSink DeadLetterSink
+
+	// TenantKey enables per-tenant index routing. It names the context
+	// value (typically populated from an incoming request header) or,
+	// failing that, the process tag that holds the tenant for a span.
+	// When empty, all spans are written to the shared, non-tenanted
+	// indices.
+	TenantKey string
 }
 
 // NewSpanWriter creates a new SpanWriter for use
@@ -83,12 +229,34 @@ func NewSpanWriter(p SpanWriterParams) *SpanWriter {
 		indexCacheTTL = indexCacheTTLDefault
 	}
 
+	bulkWorkers := p.BulkWorkers
+	if bulkWorkers == 0 {
+		bulkWorkers = bulkWorkersDefault
+	}
+	bulkActions := p.BulkActions
+	if bulkActions == 0 {
+		bulkActions = bulkActionsDefault
+	}
+	bulkSize := p.BulkSize
+	if bulkSize == 0 {
+		bulkSize = bulkSizeDefault
+	}
+	bulkFlushInterval := p.BulkFlushInterval
+	if bulkFlushInterval == 0 {
+		bulkFlushInterval = bulkFlushIntervalDefault
+	}
+	maxRetries := p.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = maxRetriesDefault
+	}
+
 	serviceOperationStorage := NewServiceOperationStorage(p.Client, p.Logger, serviceCacheTTL)
-	return &SpanWriter{
+	w := &SpanWriter{
 		client: p.Client,
 		logger: p.Logger,
 		writerMetrics: spanWriterMetrics{
 			indexCreate: storageMetrics.NewWriteMetrics(p.MetricsFactory, "index_create"),
+			bulkIndex:   storageMetrics.NewWriteMetrics(p.MetricsFactory, "bulk_index"),
 		},
 		serviceWriter: serviceOperationStorage.Write,
 		indexCache: cache.NewLRUWithOptions(
@@ -97,16 +265,283 @@ func NewSpanWriter(p SpanWriterParams) *SpanWriter {
 				TTL: indexCacheTTL,
 			},
 		),
-		spanConverter:    dbmodel.NewFromDomain(p.AllTagsAsFields, p.TagKeysAsFields, p.TagDotReplacement),
-		spanServiceIndex: getSpanAndServiceIndexFn(p.Archive, p.UseReadWriteAliases, p.IndexPrefix, p.SpanIndexDateLayout, p.ServiceIndexDateLayout),
+		spanConverter:     dbmodel.NewFromDomain(p.AllTagsAsFields, p.TagKeysAsFields, p.TagDotReplacement),
+		spanServiceIndex:  getSpanAndServiceIndexFn(p.Archive, p.UseReadWriteAliases || p.UseILM, p.IndexPrefix, p.SpanIndexDateLayout, p.ServiceIndexDateLayout),
+		useILM:            p.UseILM,
+		ilmPolicyName:     p.ILMPolicyName,
+		rolloverMaxAge:    p.RolloverMaxAge,
+		rolloverMaxDocs:   p.RolloverMaxDocs,
+		rolloverMaxSize:   p.RolloverMaxSize,
+		metricsFactory:    p.MetricsFactory,
+		bulkWorkers:       bulkWorkers,
+		bulkActions:       bulkActions,
+		bulkSize:          bulkSize,
+		bulkFlushInterval: bulkFlushInterval,
+		maxRetries:        maxRetries,
+		bulkQueue:         make(chan *bulkItem, bulkWorkers*bulkActions*bulkQueueSizeFactor),
+		closeCh:           make(chan struct{}),
+		pendingRetries:    make(map[*bulkItem]*time.Timer),
+		shardFailureCache: cache.NewLRUWithOptions(
+			shardFailureCacheCapacity,
+			&cache.Options{
+				TTL: indexCacheTTL,
+			},
+		),
+		tenantLocks:       make(map[string]*sync.Mutex),
+		deadLetterSink:    p.DeadLetterSink,
+		tenantKey:         p.TenantKey,
+	}
+	w.startBulkWorkers()
+	return w
+}
+
+// startBulkWorkers launches the configured number of goroutines that drain
+// bulkQueue, batching items until BulkActions/BulkSize/BulkFlushInterval is
+// reached, and flush them to Elasticsearch as a single bulk request.
+func (s *SpanWriter) startBulkWorkers() {
+	for i := 0; i < s.bulkWorkers; i++ {
+		s.bulkWg.Add(1)
+		go s.runBulkWorker()
+	}
+}
+
+func (s *SpanWriter) runBulkWorker() {
+	defer s.bulkWg.Done()
+	batch := make([]*bulkItem, 0, s.bulkActions)
+	batchSize := 0
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		s.flushBatch(batch)
+		batch = make([]*bulkItem, 0, s.bulkActions)
+		batchSize = 0
+	}
+	ticker := time.NewTicker(s.bulkFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case item, ok := <-s.bulkQueue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, item)
+			batchSize += estimateSize(item.doc)
+			if len(batch) >= s.bulkActions || batchSize >= s.bulkSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.closeCh:
+			// Drain whatever is already queued before exiting so Close()
+			// can guarantee pending spans were flushed.
+			for {
+				select {
+				case item := <-s.bulkQueue:
+					batch = append(batch, item)
+					batchSize += estimateSize(item.doc)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// estimateSize returns a rough byte-size estimate for a bulk item, used
+// only to decide when BulkSize has been reached; it need not be exact.
+func estimateSize(doc *dbmodel.Span) int {
+	return len(doc.TraceID) + len(doc.SpanID) + len(doc.OperationName) + 256
+}
+
+// flushBatch sends a batch of queued items to Elasticsearch as a single
+// bulk request, retrying items that fail with a retryable (429/503) status
+// using exponential backoff, up to MaxRetries, before giving up on them.
+func (s *SpanWriter) flushBatch(batch []*bulkItem) {
+	if len(batch) == 0 {
+		return
+	}
+	bulkSvc := s.client().Bulk()
+	for _, item := range batch {
+		bulkSvc.Add(es.NewBulkIndexRequest().Index(item.indexName).Type(item.typ).Doc(item.doc))
+	}
+	resp, err := bulkSvc.Do(context.Background())
+	if err != nil {
+		s.retryOrDrop(batch, err.Error())
+		return
+	}
+
+	for i, item := range resp.Items() {
+		switch classifyBulkStatus(item.Status) {
+		case bulkResultOK:
+			s.writerMetrics.bulkIndex.Emit(nil, 0)
+		case bulkResultRetryable:
+			s.writerMetrics.bulkIndex.Emit(errors.New(item.Error), 0)
+			s.shardFailureCounter(item.Index).Inc(1)
+			s.retryOrDrop([]*bulkItem{batch[i]}, item.Error)
+		case bulkResultPermanent:
+			s.writerMetrics.bulkIndex.Emit(errors.New(item.Error), 0)
+			s.shardFailureCounter(item.Index).Inc(1)
+			s.logger.Error("elasticsearch bulk index failed permanently",
+				zap.String("index", item.Index), zap.Int("status", item.Status), zap.String("reason", item.Error))
+			s.sendToDeadLetter(batch[i], item.Error)
+		}
+	}
+}
+
+// bulkResultKind classifies a single bulk response item's outcome.
+type bulkResultKind int
+
+const (
+	bulkResultOK bulkResultKind = iota
+	bulkResultRetryable
+	bulkResultPermanent
+)
+
+// classifyBulkStatus maps a bulk item's HTTP-like status code to how
+// flushBatch should handle it: 429 (too many requests) and 503 (service
+// unavailable) are transient and worth retrying; anything else in the
+// non-2xx range is treated as permanent and routed to the dead letter sink.
+func classifyBulkStatus(status int) bulkResultKind {
+	if status == 0 || status < 300 {
+		return bulkResultOK
+	}
+	if status == 429 || status == 503 {
+		return bulkResultRetryable
+	}
+	return bulkResultPermanent
+}
+
+// retryOrDrop schedules a retry for items that have not yet exceeded
+// MaxRetries, after an exponential backoff that doubles with each attempt.
+// Items that have exhausted their retries, or that fail while the writer
+// is closing, are sent to the dead letter sink instead of being scheduled
+// for a backoff that Close() may never see through to completion.
+func (s *SpanWriter) retryOrDrop(batch []*bulkItem, reason string) {
+	for _, item := range batch {
+		item.attempts++
+		if item.attempts > s.maxRetries || s.isClosing() {
+			s.logger.Error("dropping span after exhausting bulk retries",
+				zap.String("index", item.indexName), zap.Int("attempts", item.attempts), zap.String("reason", reason))
+			s.sendToDeadLetter(item, reason)
+			continue
+		}
+		s.scheduleRetry(item)
+	}
+}
+
+// isClosing reports whether Close() has started, i.e. closeCh has been
+// closed.
+func (s *SpanWriter) isClosing() bool {
+	select {
+	case <-s.closeCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// scheduleRetry re-sends item to Elasticsearch after an exponential
+// backoff that doubles with each attempt, capped at backoffMax. Retries are flushed directly
+// (not via bulkQueue) so they never depend on a bulk worker still being
+// around to consume them; the timer is tracked in pendingRetries and
+// retryWg so that Close() can cancel it and flush the item immediately
+// instead of losing it mid-backoff.
+func (s *SpanWriter) scheduleRetry(item *bulkItem) {
+	backoff := backoffBase << uint(item.attempts-1)
+	if backoff > backoffMax {
+		backoff = backoffMax
+	}
+	s.retryWg.Add(1)
+	timer := time.AfterFunc(backoff, func() {
+		defer s.retryWg.Done()
+		s.removePendingRetry(item)
+		s.flushBatch([]*bulkItem{item})
+	})
+	s.addPendingRetry(item, timer)
+}
+
+func (s *SpanWriter) addPendingRetry(item *bulkItem, timer *time.Timer) {
+	s.pendingMu.Lock()
+	s.pendingRetries[item] = timer
+	s.pendingMu.Unlock()
+}
+
+func (s *SpanWriter) removePendingRetry(item *bulkItem) {
+	s.pendingMu.Lock()
+	delete(s.pendingRetries, item)
+	s.pendingMu.Unlock()
+}
+
+// forceFirePendingRetries cancels every retry timer that hasn't fired yet
+// and flushes those items immediately, instead of waiting out their
+// remaining backoff. It is called once, from Close(). Timers that already
+// fired (or are in the process of firing) are left alone: their own
+// goroutine owns the corresponding retryWg.Done() call.
+func (s *SpanWriter) forceFirePendingRetries() {
+	s.pendingMu.Lock()
+	due := make([]*bulkItem, 0, len(s.pendingRetries))
+	for item, timer := range s.pendingRetries {
+		if timer.Stop() {
+			due = append(due, item)
+		}
+		delete(s.pendingRetries, item)
+	}
+	s.pendingMu.Unlock()
+
+	for _, item := range due {
+		item := item
+		go func() {
+			defer s.retryWg.Done()
+			s.flushBatch([]*bulkItem{item})
+		}()
 	}
 }
 
-// CreateTemplates creates index templates.
+// sendToDeadLetter forwards a span that permanently failed bulk indexing
+// to the configured DeadLetterSink, if any. Errors from the sink itself
+// are logged rather than retried, since there is nowhere further to send
+// the span.
+func (s *SpanWriter) sendToDeadLetter(item *bulkItem, reason string) {
+	if s.deadLetterSink == nil {
+		return
+	}
+	if err := s.deadLetterSink.Send(context.Background(), item.doc, reason); err != nil {
+		s.logger.Error("failed to write span to dead letter sink",
+			zap.String("index", item.indexName), zap.Error(err))
+	}
+}
+
+// shardFailureCounter returns (creating if necessary) a per-shard counter
+// tracking permanent and retryable bulk failures for the given index. The
+// counter is kept in shardFailureCache rather than an unbounded map, so a
+// long-lived writer using date-suffixed daily indices doesn't accumulate one
+// entry per index per day for the life of the process.
+func (s *SpanWriter) shardFailureCounter(index string) metrics.Counter {
+	if c, ok := s.shardFailureCache.Get(index).(metrics.Counter); ok {
+		return c
+	}
+	c := s.metricsFactory.Namespace(metrics.NSOptions{Name: "bulk_index", Tags: nil}).
+		Counter(metrics.Options{Name: "failures", Tags: map[string]string{"index": index}})
+	s.shardFailureCache.Put(index, c)
+	return c
+}
+
+// CreateTemplates creates index templates. When the writer is configured
+// with UseILM, it also installs the ILM policy and bootstraps the initial
+// `-000001` backing indices for the span and service write aliases, so
+// operators no longer need to run the standalone jaeger-es-rollover script
+// before the first write.
 func (s *SpanWriter) CreateTemplates(spanTemplate, serviceTemplate, indexPrefix string) error {
 	if indexPrefix != "" && !strings.HasSuffix(indexPrefix, "-") {
 		indexPrefix += "-"
 	}
+	s.templatesIndexPrefix = indexPrefix
+	s.spanTemplate = spanTemplate
+	s.serviceTemplate = serviceTemplate
+
 	_, err := s.client().CreateTemplate(indexPrefix + "jaeger-span").Body(spanTemplate).Do(context.Background())
 	if err != nil {
 		return err
@@ -115,20 +550,145 @@ func (s *SpanWriter) CreateTemplates(spanTemplate, serviceTemplate, indexPrefix
 	if err != nil {
 		return err
 	}
+	if s.useILM {
+		if err := s.createILMPolicy(); err != nil {
+			return err
+		}
+		if err := s.bootstrapWriteIndex(indexPrefix + spanIndex + "write"); err != nil {
+			return err
+		}
+		if err := s.bootstrapWriteIndex(indexPrefix + serviceIndex + "write"); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// spanAndServiceIndexFn returns names of span and service indices
-type spanAndServiceIndexFn func(spanTime time.Time) (string, string)
+// createILMPolicy installs (or updates) the ILM policy backing the
+// rollover-managed write aliases, using the rollover conditions configured
+// on SpanWriterParams.
+func (s *SpanWriter) createILMPolicy() error {
+	policy := map[string]interface{}{
+		"policy": map[string]interface{}{
+			"phases": map[string]interface{}{
+				"hot": map[string]interface{}{
+					"actions": map[string]interface{}{
+						"rollover": rolloverConditions(s.rolloverMaxAge, s.rolloverMaxDocs, s.rolloverMaxSize),
+					},
+				},
+			},
+		},
+	}
+	return s.client().CreateILMPolicy(s.ilmPolicyName).Body(policy).Do(context.Background())
+}
+
+// bootstrapWriteIndex creates the initial `-000001` backing index for a
+// rollover alias, marking it as the write index via `is_write_index` and
+// attaching the ILM policy and rollover alias in its settings. It is
+// safe to call repeatedly — e.g. on every collector startup — since it first
+// checks whether the alias already resolves to a write index, and tolerates
+// Elasticsearch rejecting the create as already existing if that check loses
+// a race.
+func (s *SpanWriter) bootstrapWriteIndex(writeAlias string) error {
+	exists, err := s.client().AliasExists(writeAlias).Do(context.Background())
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	body := map[string]interface{}{
+		"settings": map[string]interface{}{
+			"index.lifecycle.name":           s.ilmPolicyName,
+			"index.lifecycle.rollover_alias": writeAlias,
+		},
+		"aliases": map[string]interface{}{
+			writeAlias: map[string]interface{}{
+				"is_write_index": true,
+			},
+		},
+	}
+	_, err = s.client().CreateIndex(writeAlias + ilmInitialIndexSuffix).Body(body).Do(context.Background())
+	if err != nil && isAlreadyExistsErr(err) {
+		return nil
+	}
+	return err
+}
+
+// isAlreadyExistsErr reports whether err is Elasticsearch's
+// resource_already_exists_exception, returned when CreateIndex targets a
+// name a concurrent caller already created between our AliasExists check
+// and this call.
+func isAlreadyExistsErr(err error) bool {
+	return strings.Contains(err.Error(), "resource_already_exists_exception") ||
+		strings.Contains(err.Error(), "already exists")
+}
+
+func rolloverConditions(maxAge time.Duration, maxDocs int, maxSize string) map[string]interface{} {
+	conditions := map[string]interface{}{}
+	if maxAge > 0 {
+		conditions["max_age"] = maxAge.String()
+	}
+	if maxDocs > 0 {
+		conditions["max_docs"] = maxDocs
+	}
+	if maxSize != "" {
+		conditions["max_size"] = maxSize
+	}
+	return conditions
+}
+
+// Rollover triggers a manual `_rollover` call against the span and service
+// write aliases of the default (non-tenant) indices, and of every tenant
+// that has written at least one span, so operators can invoke it on
+// demand (e.g. from an admin sub-command) instead of depending solely on
+// ILM's background rollover check or the standalone jaeger-es-rollover
+// script.
+func (s *SpanWriter) Rollover(ctx context.Context) error {
+	if !s.useILM {
+		return errRolloverRequiresILM
+	}
+	if err := s.rolloverTenant(ctx, ""); err != nil {
+		return err
+	}
+	var rangeErr error
+	s.tenantsBootstrapped.Range(func(key, _ interface{}) bool {
+		if err := s.rolloverTenant(ctx, key.(string)); err != nil {
+			rangeErr = err
+			return false
+		}
+		return true
+	})
+	return rangeErr
+}
+
+// rolloverTenant triggers `_rollover` for a single tenant's (or, for "",
+// the default) span and service write aliases.
+func (s *SpanWriter) rolloverTenant(ctx context.Context, tenant string) error {
+	spanWriteAlias, serviceWriteAlias := s.spanServiceIndex(tenant, time.Now())
+	if _, err := s.client().RolloverIndex(spanWriteAlias).Conditions(rolloverConditions(s.rolloverMaxAge, s.rolloverMaxDocs, s.rolloverMaxSize)).Do(ctx); err != nil {
+		return err
+	}
+	if serviceWriteAlias == "" {
+		return nil
+	}
+	_, err := s.client().RolloverIndex(serviceWriteAlias).Conditions(rolloverConditions(s.rolloverMaxAge, s.rolloverMaxDocs, s.rolloverMaxSize)).Do(ctx)
+	return err
+}
+
+// spanAndServiceIndexFn returns the names of the span and service indices
+// for a given tenant and span time. tenant is the empty string when
+// per-tenant routing (SpanWriterParams.TenantKey) is not in use.
+type spanAndServiceIndexFn func(tenant string, spanTime time.Time) (string, string)
 
 func getSpanAndServiceIndexFn(archive, useReadWriteAliases bool, prefix, spanDateLayout string, serviceDateLayout string) spanAndServiceIndexFn {
 	if prefix != "" {
 		prefix += indexPrefixSeparator
 	}
-	spanIndexPrefix := prefix + spanIndex
-	serviceIndexPrefix := prefix + serviceIndex
 	if archive {
-		return func(date time.Time) (string, string) {
+		return func(tenant string, date time.Time) (string, string) {
+			spanIndexPrefix := tenantIndexPrefix(prefix, tenant) + spanIndex
 			if useReadWriteAliases {
 				return archiveIndex(spanIndexPrefix, archiveWriteIndexSuffix), ""
 			}
@@ -137,28 +697,144 @@ func getSpanAndServiceIndexFn(archive, useReadWriteAliases bool, prefix, spanDat
 	}
 
 	if useReadWriteAliases {
-		return func(spanTime time.Time) (string, string) {
-			return spanIndexPrefix + "write", serviceIndexPrefix + "write"
+		return func(tenant string, spanTime time.Time) (string, string) {
+			p := tenantIndexPrefix(prefix, tenant)
+			return p + spanIndex + "write", p + serviceIndex + "write"
 		}
 	}
-	return func(date time.Time) (string, string) {
-		return indexWithDate(spanIndexPrefix, spanDateLayout, date), indexWithDate(serviceIndexPrefix, serviceDateLayout, date)
+	return func(tenant string, date time.Time) (string, string) {
+		p := tenantIndexPrefix(prefix, tenant)
+		return indexWithDate(p+spanIndex, spanDateLayout, date), indexWithDate(p+serviceIndex, serviceDateLayout, date)
 	}
 }
 
-// WriteSpan writes a span and its corresponding service:operation in ElasticSearch
-func (s *SpanWriter) WriteSpan(_ context.Context, span *model.Span) error {
-	spanIndexName, serviceIndexName := s.spanServiceIndex(span.StartTime)
+// tenantIndexPrefix folds a tenant into an index prefix, producing
+// "<prefix><tenant>-" so that the resulting index reads
+// "<prefix><tenant>-jaeger-span-<date>". With no tenant, prefix is
+// returned unchanged.
+func tenantIndexPrefix(prefix, tenant string) string {
+	if tenant == "" {
+		return prefix
+	}
+	return prefix + tenant + indexPrefixSeparator
+}
+
+// WriteSpan writes a span and its corresponding service:operation in
+// ElasticSearch. When TenantKey is configured, the span is routed to that
+// tenant's indices, bootstrapping the tenant's templates on first use.
+func (s *SpanWriter) WriteSpan(ctx context.Context, span *model.Span) error {
+	tenant := s.extractTenant(ctx, span)
+	if err := s.ensureTenantTemplates(tenant); err != nil {
+		return err
+	}
+	spanIndexName, serviceIndexName := s.spanServiceIndex(tenant, span.StartTime)
 	jsonSpan := s.spanConverter.FromDomainEmbedProcess(span)
 	if serviceIndexName != "" {
 		s.writeService(serviceIndexName, jsonSpan)
 	}
-	s.writeSpan(spanIndexName, jsonSpan)
+	return s.writeSpan(spanIndexName, jsonSpan)
+}
+
+// extractTenant returns the tenant a span should be routed to: the value
+// of the TenantKey context entry (typically populated from an incoming
+// request header), falling back to a process tag of the same name.
+// Returns "" when TenantKey is unset or the tenant could not be found.
+func (s *SpanWriter) extractTenant(ctx context.Context, span *model.Span) string {
+	if s.tenantKey == "" {
+		return ""
+	}
+	if v, ok := ctx.Value(s.tenantKey).(string); ok && v != "" {
+		return v
+	}
+	if span.Process != nil {
+		for _, tag := range span.Process.Tags {
+			if tag.Key == s.tenantKey {
+				return tag.AsString()
+			}
+		}
+	}
+	return ""
+}
+
+// ensureTenantTemplates installs the per-tenant span and service templates
+// (and, under UseILM, bootstraps the tenant's write index) the first time
+// a tenant is seen, recording that fact in tenantsBootstrapped so
+// subsequent writes for the same tenant are a no-op. tenantsBootstrapped
+// is sized for "one entry per tenant that has ever written," not the
+// small, TTL-evicted indexCache used elsewhere in this file, since
+// evicting a tenant here would mean re-issuing CreateTemplate/CreateIndex
+// calls on a large fraction of writes instead of just the first one.
+//
+// A new tenant's first spans typically arrive as a burst across several
+// concurrent WriteSpan calls, not one at a time, so the check-then-act
+// against tenantsBootstrapped is guarded by a per-tenant lock: only the
+// first goroutine in the burst actually issues the create calls, and the
+// rest wait for it and then observe tenantsBootstrapped already set.
+func (s *SpanWriter) ensureTenantTemplates(tenant string) error {
+	if tenant == "" {
+		return nil
+	}
+	if _, alreadyBootstrapped := s.tenantsBootstrapped.Load(tenant); alreadyBootstrapped {
+		return nil
+	}
+
+	lock := s.lockForTenant(tenant)
+	lock.Lock()
+	defer lock.Unlock()
+	if _, alreadyBootstrapped := s.tenantsBootstrapped.Load(tenant); alreadyBootstrapped {
+		return nil
+	}
+
+	prefix := tenantIndexPrefix(s.templatesIndexPrefix, tenant)
+	if _, err := s.client().CreateTemplate(prefix + "jaeger-span").Body(s.spanTemplate).Do(context.Background()); err != nil {
+		return err
+	}
+	if _, err := s.client().CreateTemplate(prefix + "jaeger-service").Body(s.serviceTemplate).Do(context.Background()); err != nil {
+		return err
+	}
+	if s.useILM {
+		if err := s.bootstrapWriteIndex(prefix + spanIndex + "write"); err != nil {
+			return err
+		}
+		if err := s.bootstrapWriteIndex(prefix + serviceIndex + "write"); err != nil {
+			return err
+		}
+	}
+
+	s.tenantsBootstrapped.Store(tenant, struct{}{})
 	return nil
 }
 
-// Close closes SpanWriter
+// lockForTenant returns (creating if necessary) the mutex that serializes
+// ensureTenantTemplates for a single tenant.
+func (s *SpanWriter) lockForTenant(tenant string) *sync.Mutex {
+	s.tenantLocksMu.Lock()
+	defer s.tenantLocksMu.Unlock()
+	lock, ok := s.tenantLocks[tenant]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.tenantLocks[tenant] = lock
+	}
+	return lock
+}
+
+// Close closes SpanWriter. It flushes any spans still queued for a bulk
+// request, cancels any spans waiting out a retry backoff and flushes them
+// immediately instead of abandoning them mid-backoff, and flushes any
+// spans buffered in the DeadLetterSink, before closing the underlying
+// client.
 func (s *SpanWriter) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closeCh)
+	})
+	s.bulkWg.Wait()
+	s.forceFirePendingRetries()
+	s.retryWg.Wait()
+	if s.deadLetterSink != nil {
+		if err := s.deadLetterSink.Flush(context.Background()); err != nil {
+			s.logger.Error("failed to flush dead letter sink", zap.Error(err))
+		}
+	}
 	return s.client().Close()
 }
 
@@ -174,6 +850,14 @@ func (s *SpanWriter) writeService(indexName string, jsonSpan *dbmodel.Span) {
 	s.serviceWriter(indexName, jsonSpan)
 }
 
-func (s *SpanWriter) writeSpan(indexName string, jsonSpan *dbmodel.Span) {
-	s.client().Index().Index(indexName).Type(spanType).BodyJson(&jsonSpan).Add()
+// writeSpan enqueues a span to be flushed to Elasticsearch as part of a
+// bulk request. It returns an error if the bulk queue is full, signalling
+// backpressure to the caller instead of silently dropping the span.
+func (s *SpanWriter) writeSpan(indexName string, jsonSpan *dbmodel.Span) error {
+	select {
+	case s.bulkQueue <- &bulkItem{indexName: indexName, typ: spanType, doc: jsonSpan}:
+		return nil
+	default:
+		return errBulkQueueFull
+	}
 }
@@ -0,0 +1,379 @@
+// Copyright (c) 2019 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spanstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/jaegertracing/jaeger/model"
+	"github.com/jaegertracing/jaeger/pkg/es"
+	"github.com/jaegertracing/jaeger/pkg/metrics"
+	"github.com/jaegertracing/jaeger/plugin/storage/es/spanstore/dbmodel"
+)
+
+// fakeESClient is a minimal es.Client double for tests that don't need to
+// exercise real Elasticsearch calls; it embeds the interface so tests
+// only have to implement the methods they actually invoke.
+type fakeESClient struct {
+	es.Client
+
+	aliasExists    bool
+	aliasExistsErr error
+
+	createIndexErr   error
+	createIndexCalls []string
+
+	createTemplateErr   error
+	createTemplateCalls []string
+
+	createILMPolicyErr error
+
+	rolloverCalls []string
+}
+
+func (f *fakeESClient) Close() error { return nil }
+
+func (f *fakeESClient) AliasExists(name string) *fakeAliasExistsService {
+	return &fakeAliasExistsService{exists: f.aliasExists, err: f.aliasExistsErr}
+}
+
+type fakeAliasExistsService struct {
+	exists bool
+	err    error
+}
+
+func (f *fakeAliasExistsService) Do(context.Context) (bool, error) { return f.exists, f.err }
+
+func (f *fakeESClient) CreateIndex(name string) *fakeBodyService {
+	f.createIndexCalls = append(f.createIndexCalls, name)
+	return &fakeBodyService{err: f.createIndexErr}
+}
+
+func (f *fakeESClient) CreateTemplate(name string) *fakeBodyService {
+	f.createTemplateCalls = append(f.createTemplateCalls, name)
+	return &fakeBodyService{err: f.createTemplateErr}
+}
+
+func (f *fakeESClient) CreateILMPolicy(name string) *fakeILMPolicyService {
+	return &fakeILMPolicyService{err: f.createILMPolicyErr}
+}
+
+func (f *fakeESClient) RolloverIndex(alias string) *fakeRolloverService {
+	f.rolloverCalls = append(f.rolloverCalls, alias)
+	return &fakeRolloverService{}
+}
+
+// fakeRolloverService stands in for the Conditions().Do() chain used by
+// rolloverTenant.
+type fakeRolloverService struct {
+	err error
+}
+
+func (f *fakeRolloverService) Conditions(interface{}) *fakeRolloverService { return f }
+func (f *fakeRolloverService) Do(context.Context) (interface{}, error)     { return nil, f.err }
+
+// fakeBodyService stands in for the Body().Do() chain shared by
+// CreateTemplate and CreateIndex.
+type fakeBodyService struct {
+	err error
+}
+
+func (f *fakeBodyService) Body(interface{}) *fakeBodyService { return f }
+func (f *fakeBodyService) Do(context.Context) (interface{}, error) { return nil, f.err }
+
+type fakeILMPolicyService struct {
+	err error
+}
+
+func (f *fakeILMPolicyService) Body(interface{}) *fakeILMPolicyService { return f }
+func (f *fakeILMPolicyService) Do(context.Context) error               { return f.err }
+
+// fakeDeadLetterSink records every span handed to it, so tests can assert
+// on what the writer gave up on.
+type fakeDeadLetterSink struct {
+	sent    []*dbmodel.Span
+	flushed bool
+}
+
+func (f *fakeDeadLetterSink) Send(_ context.Context, span *dbmodel.Span, _ string) error {
+	f.sent = append(f.sent, span)
+	return nil
+}
+
+func (f *fakeDeadLetterSink) Flush(_ context.Context) error {
+	f.flushed = true
+	return nil
+}
+
+func newTestSpanWriter(p SpanWriterParams) *SpanWriter {
+	if p.Client == nil {
+		p.Client = func() es.Client { return &fakeESClient{} }
+	}
+	if p.Logger == nil {
+		p.Logger = zap.NewNop()
+	}
+	if p.MetricsFactory == nil {
+		p.MetricsFactory = metrics.NullFactory
+	}
+	return NewSpanWriter(p)
+}
+
+func TestRolloverRequiresILM(t *testing.T) {
+	w := newTestSpanWriter(SpanWriterParams{UseILM: false})
+	err := w.Rollover(context.Background())
+	assert.EqualError(t, err, "rollover requires SpanWriterParams.UseILM to be enabled")
+}
+
+// TestBootstrapWriteIndexSkipsCreateWhenAliasAlreadyExists guards against a
+// regression where bootstrapWriteIndex unconditionally issues CreateIndex on
+// every call: on a real cluster, a second CreateTemplates run (e.g. after a
+// collector restart) would otherwise hit Elasticsearch's
+// resource_already_exists_exception instead of being a no-op.
+func TestBootstrapWriteIndexSkipsCreateWhenAliasAlreadyExists(t *testing.T) {
+	fake := &fakeESClient{aliasExists: true}
+	w := newTestSpanWriter(SpanWriterParams{Client: func() es.Client { return fake }})
+
+	assert.NoError(t, w.bootstrapWriteIndex("jaeger-span-write"))
+	assert.Empty(t, fake.createIndexCalls)
+}
+
+// TestBootstrapWriteIndexTreatsAlreadyExistsErrorAsSuccess covers the race
+// where AliasExists reports false but a concurrent caller creates the
+// backing index first: CreateIndex's resource_already_exists_exception must
+// be swallowed, not bubbled up as a WriteSpan/CreateTemplates failure.
+func TestBootstrapWriteIndexTreatsAlreadyExistsErrorAsSuccess(t *testing.T) {
+	fake := &fakeESClient{
+		aliasExists:    false,
+		createIndexErr: errors.New("elastic: Error 400 (Bad Request): index [jaeger-span-write-000001] already exists [type=resource_already_exists_exception]"),
+	}
+	w := newTestSpanWriter(SpanWriterParams{Client: func() es.Client { return fake }})
+
+	assert.NoError(t, w.bootstrapWriteIndex("jaeger-span-write"))
+	assert.Equal(t, []string{"jaeger-span-write-000001"}, fake.createIndexCalls)
+}
+
+func TestBootstrapWriteIndexPropagatesOtherErrors(t *testing.T) {
+	fake := &fakeESClient{createIndexErr: errors.New("connection refused")}
+	w := newTestSpanWriter(SpanWriterParams{Client: func() es.Client { return fake }})
+
+	assert.EqualError(t, w.bootstrapWriteIndex("jaeger-span-write"), "connection refused")
+}
+
+// TestCreateTemplatesBootstrapsILM exercises CreateTemplates end to end with
+// UseILM enabled: it must install the ILM policy and bootstrap both the span
+// and service write indices, not just the two templates.
+func TestCreateTemplatesBootstrapsILM(t *testing.T) {
+	fake := &fakeESClient{aliasExists: false}
+	w := newTestSpanWriter(SpanWriterParams{
+		UseILM: true,
+		Client: func() es.Client { return fake },
+	})
+
+	require.NoError(t, w.CreateTemplates("span-template", "service-template", "myprefix"))
+
+	assert.ElementsMatch(t, []string{"myprefix-jaeger-span", "myprefix-jaeger-service"}, fake.createTemplateCalls)
+	assert.ElementsMatch(t, []string{"myprefix-jaeger-span-write-000001", "myprefix-jaeger-service-write-000001"}, fake.createIndexCalls)
+}
+
+func TestGetSpanAndServiceIndexFnReadWriteAliases(t *testing.T) {
+	fn := getSpanAndServiceIndexFn(false, true, "myprefix", "", "")
+	spanIndexName, serviceIndexName := fn("", time.Time{})
+	assert.Equal(t, "myprefix-jaeger-span-write", spanIndexName)
+	assert.Equal(t, "myprefix-jaeger-service-write", serviceIndexName)
+}
+
+func TestTenantIndexPrefix(t *testing.T) {
+	assert.Equal(t, "myprefix-", tenantIndexPrefix("myprefix-", ""))
+	assert.Equal(t, "myprefix-acme-", tenantIndexPrefix("myprefix-", "acme"))
+}
+
+// TestClassifyBulkStatus covers flushBatch's retry-vs-permanent decision
+// for the bulk response status codes Elasticsearch actually returns.
+func TestClassifyBulkStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   bulkResultKind
+	}{
+		{status: 0, want: bulkResultOK},
+		{status: 201, want: bulkResultOK},
+		{status: 429, want: bulkResultRetryable},
+		{status: 503, want: bulkResultRetryable},
+		{status: 400, want: bulkResultPermanent},
+		{status: 409, want: bulkResultPermanent},
+		{status: 500, want: bulkResultPermanent},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, classifyBulkStatus(test.status))
+	}
+}
+
+// TestShardFailureCounterIsBounded guards against a regression to an
+// unbounded per-index counter map: with date-suffixed daily indices (no
+// UseReadWriteAliases/UseILM), a long-lived writer sees a new index name
+// every day, so the cache backing these counters must evict rather than
+// grow forever.
+func TestShardFailureCounterIsBounded(t *testing.T) {
+	w := newTestSpanWriter(SpanWriterParams{})
+	for i := 0; i < shardFailureCacheCapacity+10; i++ {
+		w.shardFailureCounter(fmt.Sprintf("jaeger-span-%d", i))
+	}
+	// The earliest indices must have been evicted once the cache's bounded
+	// capacity was exceeded, instead of being held onto forever.
+	assert.Nil(t, w.shardFailureCache.Get("jaeger-span-0"))
+}
+
+func TestRetryOrDropDeadLettersAfterMaxRetries(t *testing.T) {
+	sink := &fakeDeadLetterSink{}
+	w := newTestSpanWriter(SpanWriterParams{MaxRetries: 1, DeadLetterSink: sink})
+	item := &bulkItem{indexName: "jaeger-span-000001", doc: &dbmodel.Span{TraceID: "abc"}, attempts: 1}
+
+	w.retryOrDrop([]*bulkItem{item}, "boom")
+
+	if assert.Len(t, sink.sent, 1) {
+		assert.Equal(t, "abc", sink.sent[0].TraceID)
+	}
+	w.Close()
+}
+
+func TestRetryOrDropDeadLettersWhileClosing(t *testing.T) {
+	sink := &fakeDeadLetterSink{}
+	w := newTestSpanWriter(SpanWriterParams{MaxRetries: 5, DeadLetterSink: sink})
+	close(w.closeCh)
+	item := &bulkItem{indexName: "jaeger-span-000001", doc: &dbmodel.Span{TraceID: "xyz"}, attempts: 0}
+
+	// Even though attempts is well within MaxRetries, a writer that is
+	// shutting down must not schedule a new backoff timer that Close()
+	// has no way of waiting on.
+	w.retryOrDrop([]*bulkItem{item}, "writer closing")
+
+	if assert.Len(t, sink.sent, 1) {
+		assert.Equal(t, "xyz", sink.sent[0].TraceID)
+	}
+	assert.Empty(t, w.pendingRetries)
+}
+
+// TestEnsureTenantTemplatesDedupesConcurrentBootstrap covers the normal
+// case of a brand-new tenant's first spans arriving as a concurrent burst:
+// only one goroutine should actually issue the CreateTemplate calls.
+func TestEnsureTenantTemplatesDedupesConcurrentBootstrap(t *testing.T) {
+	fake := &fakeESClient{}
+	w := newTestSpanWriter(SpanWriterParams{Client: func() es.Client { return fake }})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, w.ensureTenantTemplates("acme"))
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, fake.createTemplateCalls, 2)
+}
+
+// TestWriteSpanBootstrapsAndRoutesNewTenant exercises WriteSpan end to end
+// for a brand-new tenant: it must bootstrap that tenant's templates exactly
+// once and enqueue the span under a tenant-qualified index name.
+func TestWriteSpanBootstrapsAndRoutesNewTenant(t *testing.T) {
+	fake := &fakeESClient{}
+	w := newTestSpanWriter(SpanWriterParams{
+		TenantKey: "tenant",
+		Archive:   true, // keeps WriteSpan from also routing to the service index/writer
+		Client:    func() es.Client { return fake },
+	})
+	ctx := context.WithValue(context.Background(), "tenant", "acme")
+	// Stop the bulk workers so the enqueued span stays in bulkQueue for
+	// the assertion below instead of being picked up by a worker.
+	close(w.closeCh)
+	w.bulkWg.Wait()
+
+	require.NoError(t, w.WriteSpan(ctx, &model.Span{StartTime: time.Now()}))
+
+	assert.ElementsMatch(t, []string{"acme-jaeger-span", "acme-jaeger-service"}, fake.createTemplateCalls)
+	_, bootstrapped := w.tenantsBootstrapped.Load("acme")
+	assert.True(t, bootstrapped)
+
+	select {
+	case item := <-w.bulkQueue:
+		assert.Contains(t, item.indexName, "acme")
+	default:
+		t.Fatal("expected the span to be enqueued for bulk indexing")
+	}
+}
+
+// TestRolloverIteratesKnownTenants covers the tenant-aware half of
+// Rollover: once tenants have written spans, rolling over must hit their
+// write aliases too, not just the default (non-tenant) ones.
+func TestRolloverIteratesKnownTenants(t *testing.T) {
+	fake := &fakeESClient{}
+	w := newTestSpanWriter(SpanWriterParams{UseILM: true, Client: func() es.Client { return fake }})
+	w.tenantsBootstrapped.Store("acme", struct{}{})
+	w.tenantsBootstrapped.Store("globex", struct{}{})
+
+	require.NoError(t, w.Rollover(context.Background()))
+
+	assert.ElementsMatch(t, []string{
+		"jaeger-span-write", "jaeger-service-write",
+		"acme-jaeger-span-write", "acme-jaeger-service-write",
+		"globex-jaeger-span-write", "globex-jaeger-service-write",
+	}, fake.rolloverCalls)
+}
+
+func TestCloseWithNoPendingWork(t *testing.T) {
+	w := newTestSpanWriter(SpanWriterParams{})
+	assert.NoError(t, w.Close())
+}
+
+// TestEnsureTenantTemplatesSkipsWhenAlreadyBootstrapped checks that once a
+// tenant is marked bootstrapped, ensureTenantTemplates does not touch the
+// ES client for it again.
+func TestEnsureTenantTemplatesSkipsWhenAlreadyBootstrapped(t *testing.T) {
+	w := newTestSpanWriter(SpanWriterParams{
+		Client: func() es.Client {
+			t.Fatal("client must not be used for a tenant that was already bootstrapped")
+			return nil
+		},
+	})
+	w.tenantsBootstrapped.Store("acme", struct{}{})
+
+	assert.NoError(t, w.ensureTenantTemplates("acme"))
+}
+
+// TestCloseFlushesDeadLetterSinkAfterRetriesSettle guards the ordering
+// Close() relies on to honor the DeadLetterSink's "no spans lost" contract:
+// the sink must not be flushed until every in-flight bulk/retry item has
+// either been re-indexed or handed to the sink.
+func TestCloseFlushesDeadLetterSinkAfterRetriesSettle(t *testing.T) {
+	sink := &fakeDeadLetterSink{}
+	w := newTestSpanWriter(SpanWriterParams{MaxRetries: 1, DeadLetterSink: sink})
+	item := &bulkItem{indexName: "jaeger-span-000001", doc: &dbmodel.Span{TraceID: "t1"}, attempts: 1}
+	w.retryOrDrop([]*bulkItem{item}, "boom")
+
+	assert.NoError(t, w.Close())
+	assert.True(t, sink.flushed)
+	if assert.Len(t, sink.sent, 1) {
+		assert.Equal(t, "t1", sink.sent[0].TraceID)
+	}
+}